@@ -0,0 +1,171 @@
+package binance
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// depthBuffer keeps a single symbol's local order book consistent with
+// Binance's diff-depth stream. Per Binance's documented procedure, the
+// caller must open the websocket and start buffering *before* fetching the
+// REST snapshot, or updates that land in the gap between snapshot and
+// connect are lost; depthBuffer assumes its deliver function is already
+// wired into a live subscription by the time resync is first called, and
+// simply queues anything it sees before that.
+//
+// Once synced, it validates the U <= lastUpdateId+1 <= u continuity rule on
+// every event and drops anything with u <= lastUpdateId, replaying the
+// snapshot from scratch whenever that rule is violated.
+type depthBuffer struct {
+	service Service
+	symbol  string
+	out     chan *DepthEvent
+	logger  log.Logger
+
+	mu            sync.Mutex
+	haveSnapshot  bool
+	synced        bool
+	lastUpdateID  int64
+	pendingEvents []*DepthEvent
+
+	// sendMu serializes everything sent on out, and is acquired by resync
+	// before b.mu is released, so the base snapshot event is always queued
+	// onto out before a concurrent handle() - which cannot reach its own
+	// send until it observes haveSnapshot via b.mu - can send a diff event
+	// ahead of it.
+	sendMu sync.Mutex
+}
+
+func newDepthBuffer(service Service, symbol string, out chan *DepthEvent, logger log.Logger) *depthBuffer {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	return &depthBuffer{
+		service: service,
+		symbol:  symbol,
+		out:     out,
+		logger:  logger,
+	}
+}
+
+// resync fetches a fresh REST snapshot and replays whatever was buffered in
+// the meantime against it. It must be safe to call concurrently with handle,
+// since the very first call races the websocket's first few messages by
+// design (connect-then-snapshot, not snapshot-then-connect).
+func (b *depthBuffer) resync() error {
+	ob, err := b.service.OrderBook(OrderBookRequest{Symbol: b.symbol, Limit: 1000})
+	if err != nil {
+		return errors.Wrap(err, "unable to fetch order book snapshot")
+	}
+
+	b.mu.Lock()
+	b.lastUpdateID = ob.LastUpdateID
+	b.synced = false
+	b.haveSnapshot = true
+
+	pending := b.pendingEvents
+	b.pendingEvents = nil
+
+	var toDeliver []*DepthEvent
+	for _, evt := range pending {
+		if evt.FinalUpdateID <= b.lastUpdateID {
+			continue
+		}
+		d, gap := b.applyLocked(evt)
+		if d != nil {
+			toDeliver = append(toDeliver, d)
+		}
+		if gap {
+			// This snapshot is already stale relative to what we buffered;
+			// stop replaying and let the next live event (or another
+			// resync) pick up the rest.
+			break
+		}
+	}
+	// Take sendMu before releasing b.mu: any handle() that can possibly
+	// reach its own send must first observe haveSnapshot via b.mu, which we
+	// only released just now, so it cannot already hold sendMu ahead of us.
+	b.sendMu.Lock()
+	b.mu.Unlock()
+	defer b.sendMu.Unlock()
+
+	b.out <- &DepthEvent{
+		Symbol:       b.symbol,
+		LastUpdateID: ob.LastUpdateID,
+		Bids:         ob.Bids,
+		Asks:         ob.Asks,
+	}
+	for _, evt := range toDeliver {
+		b.out <- evt
+	}
+	return nil
+}
+
+// handle decodes a raw diff-depth payload and feeds it through the
+// continuity check, resyncing against a fresh snapshot if a gap is found.
+// Events that arrive before the first snapshot are simply queued.
+func (b *depthBuffer) handle(raw json.RawMessage) {
+	evt := &DepthEvent{}
+	if err := json.Unmarshal(raw, evt); err != nil {
+		level.Error(b.logger).Log("symbol", b.symbol, "err", err)
+		return
+	}
+
+	b.mu.Lock()
+	if !b.haveSnapshot {
+		b.pendingEvents = append(b.pendingEvents, evt)
+		b.mu.Unlock()
+		return
+	}
+	deliver, gap := b.applyLocked(evt)
+	b.mu.Unlock()
+
+	if deliver != nil {
+		b.sendMu.Lock()
+		b.out <- deliver
+		b.sendMu.Unlock()
+	}
+	if gap {
+		if err := b.resync(); err != nil {
+			level.Error(b.logger).Log("msg", "depth resync failed", "symbol", b.symbol, "err", err)
+		}
+	}
+}
+
+// applyLocked validates evt against the continuity rule and, if it passes,
+// returns it for delivery. It must be called with b.mu held, and never
+// sends on b.out itself so a slow consumer can't stall the caller's lock.
+// It returns gap == true only when evt has been queued into
+// pendingEvents for the next resync to pick up.
+func (b *depthBuffer) applyLocked(evt *DepthEvent) (*DepthEvent, bool) {
+	if !b.synced {
+		// Binance guarantees the first event to apply has
+		// U <= lastUpdateId+1 <= u; anything with u <= lastUpdateId is
+		// already reflected in the snapshot.
+		if evt.FinalUpdateID <= b.lastUpdateID {
+			return nil, false
+		}
+		if evt.FirstUpdateID > b.lastUpdateID+1 {
+			b.pendingEvents = append(b.pendingEvents, evt)
+			return nil, true
+		}
+		b.synced = true
+		b.lastUpdateID = evt.FinalUpdateID
+		return evt, false
+	}
+
+	if evt.FinalUpdateID <= b.lastUpdateID {
+		return nil, false
+	}
+	if evt.FirstUpdateID != b.lastUpdateID+1 {
+		b.synced = false
+		b.pendingEvents = append(b.pendingEvents, evt)
+		return nil, true
+	}
+	b.lastUpdateID = evt.FinalUpdateID
+	return evt, false
+}
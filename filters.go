@@ -0,0 +1,203 @@
+package binance
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ExchangeInfo describes exchange-wide trading rules, currently just the
+// per-symbol filters NewOrder and NewOrderTest validate against.
+type ExchangeInfo struct {
+	Symbols []*SymbolInfo `json:"symbols"`
+}
+
+// SymbolInfo is one symbol's trading rules as returned from
+// GET /api/v3/exchangeInfo.
+type SymbolInfo struct {
+	Symbol     string          `json:"symbol"`
+	Status     string          `json:"status"`
+	BaseAsset  string          `json:"baseAsset"`
+	QuoteAsset string          `json:"quoteAsset"`
+	Filters    []*SymbolFilter `json:"filters"`
+}
+
+// SymbolFilter is a single PRICE_FILTER, LOT_SIZE or MIN_NOTIONAL entry from
+// SymbolInfo.Filters. Only the fields relevant to that FilterType are
+// populated by Binance; the rest are left as zero values.
+type SymbolFilter struct {
+	FilterType  string `json:"filterType"`
+	MinPrice    string `json:"minPrice"`
+	MaxPrice    string `json:"maxPrice"`
+	TickSize    string `json:"tickSize"`
+	MinQty      string `json:"minQty"`
+	MaxQty      string `json:"maxQty"`
+	StepSize    string `json:"stepSize"`
+	MinNotional string `json:"minNotional"`
+}
+
+// FilterError is returned by NewOrder/NewOrderTest when price or quantity
+// falls outside the symbol's cached PRICE_FILTER/LOT_SIZE/MIN_NOTIONAL
+// bounds.
+type FilterError struct {
+	Symbol string
+	Filter string
+	Reason string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("binance: %s order rejected by %s filter: %s", e.Symbol, e.Filter, e.Reason)
+}
+
+// parsedFilters holds the subset of a symbol's filters NewOrder cares about,
+// decoded once out of the string fields Binance serializes them as.
+type parsedFilters struct {
+	minPrice, maxPrice, tickSize float64
+	minQty, maxQty, stepSize     float64
+	minNotional                  float64
+
+	hasPriceFilter, hasLotSizeFilter, hasMinNotional bool
+}
+
+// symbolFilterCache holds the last ExchangeInfo result, keyed by symbol, so
+// NewOrder/NewOrderTest can validate without a round trip. It is empty until
+// ExchangeInfo has been called at least once.
+type symbolFilterCache struct {
+	mu       sync.RWMutex
+	bySymbol map[string]*parsedFilters
+}
+
+func newSymbolFilterCache() *symbolFilterCache {
+	return &symbolFilterCache{bySymbol: map[string]*parsedFilters{}}
+}
+
+func (c *symbolFilterCache) update(info *ExchangeInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, sym := range info.Symbols {
+		pf := &parsedFilters{}
+		for _, f := range sym.Filters {
+			switch f.FilterType {
+			case "PRICE_FILTER":
+				pf.minPrice, _ = strconv.ParseFloat(f.MinPrice, 64)
+				pf.maxPrice, _ = strconv.ParseFloat(f.MaxPrice, 64)
+				pf.tickSize, _ = strconv.ParseFloat(f.TickSize, 64)
+				pf.hasPriceFilter = true
+			case "LOT_SIZE":
+				pf.minQty, _ = strconv.ParseFloat(f.MinQty, 64)
+				pf.maxQty, _ = strconv.ParseFloat(f.MaxQty, 64)
+				pf.stepSize, _ = strconv.ParseFloat(f.StepSize, 64)
+				pf.hasLotSizeFilter = true
+			case "MIN_NOTIONAL":
+				pf.minNotional, _ = strconv.ParseFloat(f.MinNotional, 64)
+				pf.hasMinNotional = true
+			}
+		}
+		c.bySymbol[sym.Symbol] = pf
+	}
+}
+
+func (c *symbolFilterCache) get(symbol string) (*parsedFilters, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	pf, ok := c.bySymbol[symbol]
+	return pf, ok
+}
+
+// roundToStep rounds v down to the nearest multiple of step, matching
+// Binance's own truncation behavior for LOT_SIZE/PRICE_FILTER.
+func roundToStep(v, step float64) float64 {
+	if step <= 0 {
+		return v
+	}
+	return math.Floor(v/step+1e-9) * step
+}
+
+// validateAndRoundOrder checks price and quantity against the cached filters
+// for symbol, rounding each to the filter's tick/step size. It is a no-op
+// (returning price and quantity unchanged) if ExchangeInfo has not yet been
+// called for symbol, so callers that never opted in keep working exactly as
+// before this validation existed.
+func (as *apiService) validateAndRoundOrder(symbol string, price, quantity float64) (float64, float64, error) {
+	pf, ok := as.filters.get(symbol)
+	if !ok {
+		return price, quantity, nil
+	}
+
+	if pf.hasLotSizeFilter {
+		quantity = roundToStep(quantity, pf.stepSize)
+		if quantity < pf.minQty || (pf.maxQty > 0 && quantity > pf.maxQty) {
+			return 0, 0, &FilterError{Symbol: symbol, Filter: "LOT_SIZE",
+				Reason: fmt.Sprintf("quantity %v outside [%v, %v]", quantity, pf.minQty, pf.maxQty)}
+		}
+	}
+	if pf.hasPriceFilter && price != 0 {
+		price = roundToStep(price, pf.tickSize)
+		if price < pf.minPrice || (pf.maxPrice > 0 && price > pf.maxPrice) {
+			return 0, 0, &FilterError{Symbol: symbol, Filter: "PRICE_FILTER",
+				Reason: fmt.Sprintf("price %v outside [%v, %v]", price, pf.minPrice, pf.maxPrice)}
+		}
+	}
+	if pf.hasMinNotional && price != 0 {
+		if notional := price * quantity; notional < pf.minNotional {
+			return 0, 0, &FilterError{Symbol: symbol, Filter: "MIN_NOTIONAL",
+				Reason: fmt.Sprintf("notional %v below minimum %v", notional, pf.minNotional)}
+		}
+	}
+	return price, quantity, nil
+}
+
+// ExchangeInfo fetches every symbol's trading rules and precision filters
+// via GET /api/v3/exchangeInfo, and caches them for NewOrder/NewOrderTest to
+// validate against.
+func (as *apiService) ExchangeInfo() (*ExchangeInfo, error) {
+	res, err := as.request("GET", "api/v3/exchangeInfo", map[string]string{}, false, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch exchange info")
+	}
+	defer res.Body.Close()
+	v := &ExchangeInfo{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode exchange info response")
+	}
+	as.filters.update(v)
+	return v, nil
+}
+
+// HistoricalTradesRequest fetches older trades than AggTrades/Klines expose,
+// via GET /api/v3/historicalTrades.
+type HistoricalTradesRequest struct {
+	Symbol string
+	Limit  int
+	// FromID returns trades starting at this trade ID (inclusive). Leave
+	// zero to fetch the most recent trades.
+	FromID int64
+}
+
+// HistoricalTrades fetches historical (older than the recent-trades window)
+// market trades for a symbol via GET /api/v3/historicalTrades.
+func (as *apiService) HistoricalTrades(htr HistoricalTradesRequest) ([]*Trade, error) {
+	params := map[string]string{
+		"symbol": htr.Symbol,
+	}
+	if htr.Limit > 0 {
+		params["limit"] = strconv.Itoa(htr.Limit)
+	}
+	if htr.FromID > 0 {
+		params["fromId"] = strconv.FormatInt(htr.FromID, 10)
+	}
+	res, err := as.request("GET", "api/v3/historicalTrades", params, true, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch historical trades")
+	}
+	defer res.Body.Close()
+	v := []*Trade{}
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode historical trades response")
+	}
+	return v, nil
+}
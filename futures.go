@@ -0,0 +1,631 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// FuturesService represents the service layer for Binance's USD-margined
+// futures API (fapi.binance.com). It mirrors Service's shape for the spot
+// API so callers already familiar with NewAPIService feel at home here.
+type FuturesService interface {
+	Ping() error
+	Time() (time.Time, error)
+	ExchangeInfo() ([]*FuturesContractInfo, error)
+	Klines(kr KlinesRequest) ([]*Kline, error)
+	AggTrades(atr AggTradesRequest) ([]*AggTrade, error)
+	OrderBook(obr OrderBookRequest) (*OrderBook, error)
+
+	NewOrder(or FuturesNewOrderRequest) (*FuturesProcessedOrder, error)
+	CancelOrder(cor CancelOrderRequest) (*CanceledOrder, error)
+
+	ChangeLeverage(symbol string, leverage int) (*FuturesLeverage, error)
+	ChangeMarginType(symbol string, marginType FuturesMarginType) error
+
+	Account() (*FuturesAccount, error)
+	PositionRisk(symbol string) ([]*FuturesPositionRisk, error)
+	FundingRateHistory(symbol string, limit int) ([]*FundingRate, error)
+
+	MarkPriceWebsocket(symbol string) (chan *MarkPriceEvent, chan struct{}, error)
+	LiquidationWebsocket(symbol string) (chan *LiquidationEvent, chan struct{}, error)
+}
+
+type futuresAPIService struct {
+	URL        string
+	APIKey     string
+	Signer     Signer
+	Logger     log.Logger
+	Ctx        context.Context
+	HTTPClient *http.Client
+	Limiter    *rateLimiter
+
+	// RecvWindowMillis is sent as recvWindow on every SIGNED request,
+	// defaulting to defaultRecvWindowMillis.
+	RecvWindowMillis int64
+}
+
+// NewFuturesAPIService creates a FuturesService targeting Binance's USD-M
+// futures API, conventionally https://fapi.binance.com.
+//
+// If logger or ctx are not provided, NopLogger and Background context are
+// used as default, matching NewAPIService. See NewAPIService for the
+// available Options.
+func NewFuturesAPIService(url, apiKey string, signer Signer, logger log.Logger, ctx context.Context, opts ...Option) FuturesService {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	o := newOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	fs := &futuresAPIService{
+		URL:              url,
+		APIKey:           apiKey,
+		Signer:           signer,
+		Logger:           logger,
+		Ctx:              ctx,
+		HTTPClient:       o.httpClient(),
+		RecvWindowMillis: defaultRecvWindowMillis,
+	}
+	if o.rateLimiter {
+		fs.Limiter = newRateLimiter(logger)
+	}
+	return fs
+}
+
+func (fs *futuresAPIService) request(method string, endpoint string, params map[string]string,
+	apiKey bool, sign bool) (*http.Response, error) {
+
+	if fs.Limiter != nil {
+		fs.Limiter.wait()
+	}
+
+	url := fmt.Sprintf("%s/%s", fs.URL, endpoint)
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create request")
+	}
+	req.WithContext(fs.Ctx)
+
+	q := req.URL.Query()
+	for key, val := range params {
+		q.Add(key, val)
+	}
+	if apiKey {
+		req.Header.Add("X-MBX-APIKEY", fs.APIKey)
+	}
+	if sign {
+		q.Set("timestamp", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+		if fs.RecvWindowMillis > 0 {
+			q.Set("recvWindow", strconv.FormatInt(fs.RecvWindowMillis, 10))
+		}
+		q.Add("signature", fs.Signer.Sign([]byte(q.Encode())))
+	}
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := fs.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if fs.Limiter != nil {
+		if err := fs.Limiter.observe(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (fs *futuresAPIService) Ping() error {
+	res, err := fs.request("GET", "fapi/v1/ping", map[string]string{}, false, false)
+	if err != nil {
+		return errors.Wrap(err, "unable to ping")
+	}
+	defer res.Body.Close()
+	return nil
+}
+
+func (fs *futuresAPIService) Time() (time.Time, error) {
+	res, err := fs.request("GET", "fapi/v1/time", map[string]string{}, false, false)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "unable to fetch server time")
+	}
+	defer res.Body.Close()
+	v := &struct {
+		ServerTime int64 `json:"serverTime"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return time.Time{}, errors.Wrap(err, "unable to decode time response")
+	}
+	return time.Unix(0, v.ServerTime*int64(time.Millisecond)), nil
+}
+
+// FuturesContractInfo describes a single perpetual or delivery contract as
+// returned from GET /fapi/v1/exchangeInfo, with enough precision metadata to
+// round order price and quantity to what the instrument accepts.
+type FuturesContractInfo struct {
+	Symbol            string `json:"symbol"`
+	Pair              string `json:"pair"`
+	ContractType      string `json:"contractType"`
+	DeliveryDate      int64  `json:"deliveryDate"`
+	ContractStatus    string `json:"status"`
+	BaseAsset         string `json:"baseAsset"`
+	QuoteAsset        string `json:"quoteAsset"`
+	PricePrecision    int    `json:"pricePrecision"`
+	QuantityPrecision int    `json:"quantityPrecision"`
+	TickSize          string `json:"-"`
+	StepSize          string `json:"-"`
+}
+
+type futuresExchangeInfoResponse struct {
+	Symbols []*futuresSymbolInfo `json:"symbols"`
+}
+
+type futuresSymbolInfo struct {
+	FuturesContractInfo
+	Filters []struct {
+		FilterType string `json:"filterType"`
+		TickSize   string `json:"tickSize"`
+		StepSize   string `json:"stepSize"`
+	} `json:"filters"`
+}
+
+// ExchangeInfo fetches every futures contract's trading rules and precision
+// filters via GET /fapi/v1/exchangeInfo.
+func (fs *futuresAPIService) ExchangeInfo() ([]*FuturesContractInfo, error) {
+	res, err := fs.request("GET", "fapi/v1/exchangeInfo", map[string]string{}, false, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch futures exchange info")
+	}
+	defer res.Body.Close()
+	v := &futuresExchangeInfoResponse{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode futures exchange info response")
+	}
+	contracts := make([]*FuturesContractInfo, 0, len(v.Symbols))
+	for _, s := range v.Symbols {
+		ci := s.FuturesContractInfo
+		for _, f := range s.Filters {
+			switch f.FilterType {
+			case "PRICE_FILTER":
+				ci.TickSize = f.TickSize
+			case "LOT_SIZE":
+				ci.StepSize = f.StepSize
+			}
+		}
+		contracts = append(contracts, &ci)
+	}
+	return contracts, nil
+}
+
+// Klines fetches candlestick bars for a futures symbol via
+// GET /fapi/v1/klines.
+func (fs *futuresAPIService) Klines(kr KlinesRequest) ([]*Kline, error) {
+	params := map[string]string{
+		"symbol":   kr.Symbol,
+		"interval": string(kr.Interval),
+	}
+	if kr.Limit > 0 {
+		params["limit"] = strconv.Itoa(kr.Limit)
+	}
+	if !kr.StartTime.IsZero() {
+		params["startTime"] = strconv.FormatInt(kr.StartTime.UnixNano()/int64(time.Millisecond), 10)
+	}
+	if !kr.EndTime.IsZero() {
+		params["endTime"] = strconv.FormatInt(kr.EndTime.UnixNano()/int64(time.Millisecond), 10)
+	}
+	res, err := fs.request("GET", "fapi/v1/klines", params, false, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch futures klines")
+	}
+	defer res.Body.Close()
+	v := []*Kline{}
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode futures klines response")
+	}
+	return v, nil
+}
+
+// AggTrades fetches compressed, aggregate trades for a futures symbol via
+// GET /fapi/v1/aggTrades.
+func (fs *futuresAPIService) AggTrades(atr AggTradesRequest) ([]*AggTrade, error) {
+	params := map[string]string{
+		"symbol": atr.Symbol,
+	}
+	if atr.Limit > 0 {
+		params["limit"] = strconv.Itoa(atr.Limit)
+	}
+	if atr.FromID != 0 {
+		params["fromId"] = strconv.FormatInt(atr.FromID, 10)
+	}
+	if !atr.StartTime.IsZero() {
+		params["startTime"] = strconv.FormatInt(atr.StartTime.UnixNano()/int64(time.Millisecond), 10)
+	}
+	if !atr.EndTime.IsZero() {
+		params["endTime"] = strconv.FormatInt(atr.EndTime.UnixNano()/int64(time.Millisecond), 10)
+	}
+	res, err := fs.request("GET", "fapi/v1/aggTrades", params, false, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch futures agg trades")
+	}
+	defer res.Body.Close()
+	v := []*AggTrade{}
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode futures agg trades response")
+	}
+	return v, nil
+}
+
+// OrderBook fetches the current order book depth for a futures symbol via
+// GET /fapi/v1/depth.
+func (fs *futuresAPIService) OrderBook(obr OrderBookRequest) (*OrderBook, error) {
+	params := map[string]string{
+		"symbol": obr.Symbol,
+	}
+	if obr.Limit > 0 {
+		params["limit"] = strconv.Itoa(obr.Limit)
+	}
+	res, err := fs.request("GET", "fapi/v1/depth", params, false, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch futures order book")
+	}
+	defer res.Body.Close()
+	v := &OrderBook{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode futures order book response")
+	}
+	return v, nil
+}
+
+// FuturesNewOrderRequest places an order on the futures book. PositionSide
+// and ReduceOnly only apply when hedge mode is enabled on the account;
+// ClosePosition closes the entire open position for Symbol/PositionSide
+// regardless of Quantity.
+type FuturesNewOrderRequest struct {
+	Symbol           string
+	Side             OrderSide
+	PositionSide     string
+	Type             OrderType
+	TimeInForce      TimeInForce
+	Quantity         float64
+	Price            float64
+	ReduceOnly       bool
+	ClosePosition    bool
+	NewClientOrderID string
+}
+
+// FuturesProcessedOrder is the response to a new futures order.
+type FuturesProcessedOrder struct {
+	OrderID       int64  `json:"orderId"`
+	Symbol        string `json:"symbol"`
+	Status        string `json:"status"`
+	ClientOrderID string `json:"clientOrderId"`
+	Price         string `json:"price"`
+	AvgPrice      string `json:"avgPrice"`
+	OrigQty       string `json:"origQty"`
+	ExecutedQty   string `json:"executedQty"`
+	PositionSide  string `json:"positionSide"`
+	ReduceOnly    bool   `json:"reduceOnly"`
+	ClosePosition bool   `json:"closePosition"`
+}
+
+func (fs *futuresAPIService) NewOrder(or FuturesNewOrderRequest) (*FuturesProcessedOrder, error) {
+	params := map[string]string{
+		"symbol": or.Symbol,
+		"side":   string(or.Side),
+		"type":   string(or.Type),
+	}
+	if or.PositionSide != "" {
+		params["positionSide"] = or.PositionSide
+	}
+	if or.TimeInForce != "" {
+		params["timeInForce"] = string(or.TimeInForce)
+	}
+	if or.ClosePosition {
+		params["closePosition"] = "true"
+	} else {
+		params["quantity"] = strconv.FormatFloat(or.Quantity, 'f', -1, 64)
+		if or.ReduceOnly {
+			params["reduceOnly"] = "true"
+		}
+	}
+	if or.Price != 0 {
+		params["price"] = strconv.FormatFloat(or.Price, 'f', -1, 64)
+	}
+	if or.NewClientOrderID != "" {
+		params["newClientOrderId"] = or.NewClientOrderID
+	}
+	res, err := fs.request("POST", "fapi/v1/order", params, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to place futures order")
+	}
+	defer res.Body.Close()
+	v := &FuturesProcessedOrder{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode futures order response")
+	}
+	return v, nil
+}
+
+func (fs *futuresAPIService) CancelOrder(cor CancelOrderRequest) (*CanceledOrder, error) {
+	params := map[string]string{
+		"symbol": cor.Symbol,
+	}
+	if cor.OrderID != 0 {
+		params["orderId"] = strconv.FormatInt(cor.OrderID, 10)
+	}
+	if cor.OrigClientOrderID != "" {
+		params["origClientOrderId"] = cor.OrigClientOrderID
+	}
+	res, err := fs.request("DELETE", "fapi/v1/order", params, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to cancel futures order")
+	}
+	defer res.Body.Close()
+	v := &CanceledOrder{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode cancel futures order response")
+	}
+	return v, nil
+}
+
+// FuturesLeverage is the response to ChangeLeverage.
+type FuturesLeverage struct {
+	Symbol           string `json:"symbol"`
+	Leverage         int    `json:"leverage"`
+	MaxNotionalValue string `json:"maxNotionalValue"`
+}
+
+// ChangeLeverage sets the initial leverage for Symbol via
+// POST /fapi/v1/leverage.
+func (fs *futuresAPIService) ChangeLeverage(symbol string, leverage int) (*FuturesLeverage, error) {
+	params := map[string]string{
+		"symbol":   symbol,
+		"leverage": strconv.Itoa(leverage),
+	}
+	res, err := fs.request("POST", "fapi/v1/leverage", params, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to change leverage")
+	}
+	defer res.Body.Close()
+	v := &FuturesLeverage{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode leverage response")
+	}
+	return v, nil
+}
+
+// FuturesMarginType selects isolated or crossed margin for a futures symbol.
+type FuturesMarginType string
+
+const (
+	FuturesMarginTypeIsolated FuturesMarginType = "ISOLATED"
+	FuturesMarginTypeCrossed  FuturesMarginType = "CROSSED"
+)
+
+// ChangeMarginType switches Symbol between isolated and crossed margin via
+// POST /fapi/v1/marginType. Binance rejects this call while a position is
+// open; any non-200 response is returned as an error carrying its status
+// code, and request errors are returned wrapped.
+func (fs *futuresAPIService) ChangeMarginType(symbol string, marginType FuturesMarginType) error {
+	params := map[string]string{
+		"symbol":     symbol,
+		"marginType": string(marginType),
+	}
+	res, err := fs.request("POST", "fapi/v1/marginType", params, true, true)
+	if err != nil {
+		return errors.Wrap(err, "unable to change margin type")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("unable to change margin type: status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// FuturesAccount is a futures account snapshot from GET /fapi/v2/account.
+type FuturesAccount struct {
+	TotalWalletBalance    string                 `json:"totalWalletBalance"`
+	TotalUnrealizedProfit string                 `json:"totalUnrealizedProfit"`
+	TotalMarginBalance    string                 `json:"totalMarginBalance"`
+	AvailableBalance      string                 `json:"availableBalance"`
+	Positions             []*FuturesPositionRisk `json:"positions"`
+}
+
+// Account fetches the futures account balances and positions via
+// GET /fapi/v2/account.
+func (fs *futuresAPIService) Account() (*FuturesAccount, error) {
+	res, err := fs.request("GET", "fapi/v2/account", map[string]string{}, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch futures account")
+	}
+	defer res.Body.Close()
+	v := &FuturesAccount{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode futures account response")
+	}
+	return v, nil
+}
+
+// FuturesPositionRisk is a single open (or flat) position as returned from
+// GET /fapi/v1/positionRisk or embedded in FuturesAccount.
+type FuturesPositionRisk struct {
+	Symbol           string `json:"symbol"`
+	PositionAmt      string `json:"positionAmt"`
+	EntryPrice       string `json:"entryPrice"`
+	MarkPrice        string `json:"markPrice"`
+	UnrealizedProfit string `json:"unRealizedProfit"`
+	LiquidationPrice string `json:"liquidationPrice"`
+	Leverage         string `json:"leverage"`
+	MarginType       string `json:"marginType"`
+	PositionSide     string `json:"positionSide"`
+}
+
+// PositionRisk fetches current position risk for Symbol (or every symbol if
+// empty) via GET /fapi/v1/positionRisk.
+func (fs *futuresAPIService) PositionRisk(symbol string) ([]*FuturesPositionRisk, error) {
+	params := map[string]string{}
+	if symbol != "" {
+		params["symbol"] = symbol
+	}
+	res, err := fs.request("GET", "fapi/v1/positionRisk", params, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch position risk")
+	}
+	defer res.Body.Close()
+	v := []*FuturesPositionRisk{}
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode position risk response")
+	}
+	return v, nil
+}
+
+// FundingRate is a single historical funding rate settlement for a symbol.
+type FundingRate struct {
+	Symbol      string `json:"symbol"`
+	FundingRate string `json:"fundingRate"`
+	FundingTime int64  `json:"fundingTime"`
+}
+
+// FundingRateHistory fetches up to limit historical funding rate settlements
+// for Symbol via GET /fapi/v1/fundingRate.
+func (fs *futuresAPIService) FundingRateHistory(symbol string, limit int) ([]*FundingRate, error) {
+	params := map[string]string{
+		"symbol": symbol,
+	}
+	if limit > 0 {
+		params["limit"] = strconv.Itoa(limit)
+	}
+	res, err := fs.request("GET", "fapi/v1/fundingRate", params, false, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch funding rate history")
+	}
+	defer res.Body.Close()
+	v := []*FundingRate{}
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode funding rate history response")
+	}
+	return v, nil
+}
+
+// MarkPriceEvent is a markPriceUpdate stream payload.
+type MarkPriceEvent struct {
+	Symbol      string `json:"s"`
+	MarkPrice   string `json:"p"`
+	FundingRate string `json:"r"`
+	NextFunding int64  `json:"T"`
+	Time        int64  `json:"E"`
+}
+
+// LiquidationEvent is a forceOrder stream payload, emitted whenever any
+// account on the exchange is liquidated in Symbol.
+type LiquidationEvent struct {
+	Symbol string `json:"s"`
+	Side   string `json:"S"`
+	Price  string `json:"p"`
+	Qty    string `json:"q"`
+	Time   int64  `json:"T"`
+}
+
+const futuresStreamBaseURL = "wss://fstream.binance.com/ws"
+
+// MarkPriceWebsocket opens wss://fstream.binance.com/ws/<symbol>@markPrice,
+// streaming mark price and funding rate updates for Symbol roughly once
+// every 3 seconds until the returned stop channel is closed. Unlike
+// StreamManager, this is a single one-shot connection with no reconnect: a
+// dropped connection simply closes the event channel.
+func (fs *futuresAPIService) MarkPriceWebsocket(symbol string) (chan *MarkPriceEvent, chan struct{}, error) {
+	stream := strings.ToLower(symbol) + "@markPrice"
+	ws, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("%s/%s", futuresStreamBaseURL, stream), nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to dial mark price stream")
+	}
+
+	out := make(chan *MarkPriceEvent, 16)
+	stop := make(chan struct{})
+	go runFuturesWebsocket(ws, stop, fs.Logger, func(raw []byte) {
+		v := &MarkPriceEvent{}
+		if err := json.Unmarshal(raw, v); err != nil {
+			level.Error(fs.Logger).Log("stream", stream, "err", err)
+			return
+		}
+		out <- v
+	}, func() { close(out) })
+	return out, stop, nil
+}
+
+// LiquidationWebsocket opens wss://fstream.binance.com/ws/<symbol>@forceOrder,
+// streaming forced liquidation orders for Symbol until the returned stop
+// channel is closed.
+func (fs *futuresAPIService) LiquidationWebsocket(symbol string) (chan *LiquidationEvent, chan struct{}, error) {
+	stream := strings.ToLower(symbol) + "@forceOrder"
+	ws, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("%s/%s", futuresStreamBaseURL, stream), nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to dial liquidation stream")
+	}
+
+	out := make(chan *LiquidationEvent, 16)
+	stop := make(chan struct{})
+	go runFuturesWebsocket(ws, stop, fs.Logger, func(raw []byte) {
+		// forceOrder nests the order fields under "o"; unwrap before handing
+		// the event to the caller.
+		env := &struct {
+			Order LiquidationEvent `json:"o"`
+		}{}
+		if err := json.Unmarshal(raw, env); err != nil {
+			level.Error(fs.Logger).Log("stream", stream, "err", err)
+			return
+		}
+		v := env.Order
+		out <- &v
+	}, func() { close(out) })
+	return out, stop, nil
+}
+
+// runFuturesWebsocket reads raw messages off ws, handing each to handle,
+// until either the connection errors out or stop is closed, at which point
+// it closes ws and calls onDone.
+func runFuturesWebsocket(ws *websocket.Conn, stop chan struct{}, logger log.Logger, handle func([]byte), onDone func()) {
+	defer onDone()
+	defer ws.Close()
+
+	msgs := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			msgs <- msg
+		}
+	}()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case err := <-readErr:
+			if err != nil {
+				level.Error(logger).Log("msg", "futures stream read failed", "err", err)
+			}
+			return
+		case msg := <-msgs:
+			handle(msg)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package binance
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HTTPConfig controls the *http.Client NewAPIService and NewFuturesAPIService
+// build internally. The zero value is not safe to use directly; start from
+// DefaultHTTPConfig and override only the fields that need to change.
+type HTTPConfig struct {
+	// ProxyURL routes all requests through the given proxy. Leave empty to
+	// use the environment's proxy settings (see http.ProxyFromEnvironment).
+	ProxyURL string
+	// TLSClientConfig overrides the client's TLS configuration. Leave nil to
+	// verify certificates using the system's default trust store.
+	TLSClientConfig *tls.Config
+
+	DialTimeout         time.Duration
+	KeepAlive           time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	// Timeout bounds an entire request including redirects and reading the
+	// response body. Zero means no client-level timeout.
+	Timeout time.Duration
+}
+
+// DefaultHTTPConfig returns the connection-pool tuning this package has
+// always used, with no proxy beyond the environment's proxy settings and TLS
+// verification enabled.
+func DefaultHTTPConfig() HTTPConfig {
+	return HTTPConfig{
+		DialTimeout:         5 * time.Second,
+		KeepAlive:           5 * time.Second,
+		MaxIdleConns:        30,
+		MaxIdleConnsPerHost: 60,
+		IdleConnTimeout:     30 * time.Second,
+	}
+}
+
+func buildHTTPClient(cfg HTTPConfig) *http.Client {
+	transport := &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: cfg.KeepAlive,
+		}).DialContext,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		TLSClientConfig:     cfg.TLSClientConfig,
+		Proxy:               http.ProxyFromEnvironment,
+	}
+	if cfg.ProxyURL != "" {
+		if proxyURL, err := url.Parse(cfg.ProxyURL); err == nil {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+	}
+}
+
+// options collects the settings Option functions populate. It is unexported;
+// callers only ever see the Option constructors below.
+type options struct {
+	httpConfig   HTTPConfig
+	explicitHTTP *http.Client
+	rateLimiter  bool
+}
+
+func newOptions() *options {
+	return &options{httpConfig: DefaultHTTPConfig()}
+}
+
+func (o *options) httpClient() *http.Client {
+	if o.explicitHTTP != nil {
+		return o.explicitHTTP
+	}
+	return buildHTTPClient(o.httpConfig)
+}
+
+// Option configures optional behavior on NewAPIService and
+// NewFuturesAPIService beyond their required positional arguments.
+type Option func(*options)
+
+// WithHTTPConfig builds the service's client from cfg instead of
+// DefaultHTTPConfig. Ignored if WithHTTPClient is also supplied.
+func WithHTTPConfig(cfg HTTPConfig) Option {
+	return func(o *options) { o.httpConfig = cfg }
+}
+
+// WithHTTPClient overrides the client entirely, taking precedence over
+// WithHTTPConfig. Use this when the caller already manages its own transport
+// or connection pooling.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) { o.explicitHTTP = c }
+}
+
+// WithRateLimiter enables client-side throttling based on the
+// X-MBX-USED-WEIGHT-1M and X-MBX-ORDER-COUNT-* response headers Binance
+// returns on every request, and backs off using the Retry-After header when
+// a 429 (rate limited) or 418 (IP banned) response arrives.
+func WithRateLimiter() Option {
+	return func(o *options) { o.rateLimiter = true }
+}
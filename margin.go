@@ -0,0 +1,269 @@
+package binance
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// marginMode selects which Binance trading venue an apiService routes
+// requests through. The zero value, marginNone, is plain spot trading.
+type marginMode int
+
+const (
+	marginNone marginMode = iota
+	marginCross
+	marginIsolated
+)
+
+// UseMargin returns a copy of the Service scoped to cross-margin trading, or
+// isolated-margin trading for symbols passed to IsolatedMarginAccount and the
+// NewOrder-style calls below. The original Service is left untouched.
+func (as *apiService) UseMargin(isolated bool) Service {
+	cp := *as
+	if isolated {
+		cp.Margin = marginIsolated
+	} else {
+		cp.Margin = marginCross
+	}
+	return &cp
+}
+
+// MarginAccount describes a cross-margin account snapshot returned from
+// GET /sapi/v1/margin/account.
+type MarginAccount struct {
+	BorrowEnabled       bool                  `json:"borrowEnabled"`
+	TradeEnabled        bool                  `json:"tradeEnabled"`
+	TransferEnabled     bool                  `json:"transferEnabled"`
+	MarginLevel         string                `json:"marginLevel"`
+	TotalAssetOfBtc     string                `json:"totalAssetOfBtc"`
+	TotalLiabilityOfBtc string                `json:"totalLiabilityOfBtc"`
+	TotalNetAssetOfBtc  string                `json:"totalNetAssetOfBtc"`
+	UserAssets          []*MarginAccountAsset `json:"userAssets"`
+}
+
+// MarginAccountAsset is a single asset balance within a MarginAccount or
+// IsolatedMarginAccount.
+type MarginAccountAsset struct {
+	Asset    string `json:"asset"`
+	Borrowed string `json:"borrowed"`
+	Free     string `json:"free"`
+	Interest string `json:"interest"`
+	Locked   string `json:"locked"`
+	NetAsset string `json:"netAsset"`
+}
+
+// IsolatedMarginAccount describes one or more isolated-margin symbol pairs
+// returned from GET /sapi/v1/margin/isolated/account.
+type IsolatedMarginAccount struct {
+	Assets []*IsolatedMarginAsset `json:"assets"`
+}
+
+// IsolatedMarginAsset is a single isolated-margin symbol pair, holding the
+// base and quote asset balances independently from the cross-margin account.
+type IsolatedMarginAsset struct {
+	Symbol     string              `json:"symbol"`
+	Enabled    bool                `json:"enabled"`
+	BaseAsset  *MarginAccountAsset `json:"baseAsset"`
+	QuoteAsset *MarginAccountAsset `json:"quoteAsset"`
+}
+
+// MarginLoanRequest is used for both MarginBorrow (POST /sapi/v1/margin/loan)
+// and MarginRepay (POST /sapi/v1/margin/repay). Symbol is required when the
+// Service is scoped to isolated margin, and ignored for cross margin.
+type MarginLoanRequest struct {
+	Asset  string
+	Symbol string
+	Amount float64
+}
+
+// MarginTransferRequest moves funds between the spot account and a
+// cross-margin or isolated-margin account via POST /sapi/v1/margin/transfer
+// (or /sapi/v1/margin/isolated/transfer when the Service is isolated-scoped).
+type MarginTransferRequest struct {
+	Asset  string
+	Symbol string
+	Amount float64
+	// TransferType is 1 for spot->margin, 2 for margin->spot.
+	TransferType int
+}
+
+// MarginTransaction is the common response shape for margin loan, repay and
+// transfer operations, all of which are asynchronous on Binance's side and
+// only return a tranId to poll against.
+type MarginTransaction struct {
+	TranID int64 `json:"tranId"`
+}
+
+func (as *apiService) marginOrderEndpoint() string {
+	if as.Margin == marginIsolated {
+		return "sapi/v1/margin/isolated/order"
+	}
+	return "sapi/v1/margin/order"
+}
+
+// MarginNewOrder places a new order against /sapi/v1/margin/order, or the
+// isolated-margin variant when the Service was obtained via UseMargin(true).
+func (as *apiService) MarginNewOrder(or NewOrderRequest) (*ProcessedOrder, error) {
+	params := map[string]string{
+		"symbol":   or.Symbol,
+		"side":     string(or.Side),
+		"type":     string(or.Type),
+		"quantity": strconv.FormatFloat(or.Quantity, 'f', -1, 64),
+	}
+	if or.TimeInForce != "" {
+		params["timeInForce"] = string(or.TimeInForce)
+	}
+	if or.Price != 0 {
+		params["price"] = strconv.FormatFloat(or.Price, 'f', -1, 64)
+	}
+	if or.NewClientOrderID != "" {
+		params["newClientOrderId"] = or.NewClientOrderID
+	}
+	if as.Margin == marginIsolated {
+		params["isIsolated"] = "TRUE"
+	}
+	res, err := as.request("POST", as.marginOrderEndpoint(), params, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to place margin order")
+	}
+	defer res.Body.Close()
+	v := &ProcessedOrder{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode margin order response")
+	}
+	return v, nil
+}
+
+// MarginCancelOrder cancels an existing margin order.
+func (as *apiService) MarginCancelOrder(cor CancelOrderRequest) (*CanceledOrder, error) {
+	params := map[string]string{
+		"symbol": cor.Symbol,
+	}
+	if cor.OrderID != 0 {
+		params["orderId"] = strconv.FormatInt(cor.OrderID, 10)
+	}
+	if cor.OrigClientOrderID != "" {
+		params["origClientOrderId"] = cor.OrigClientOrderID
+	}
+	if as.Margin == marginIsolated {
+		params["isIsolated"] = "TRUE"
+	}
+	res, err := as.request("DELETE", as.marginOrderEndpoint(), params, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to cancel margin order")
+	}
+	defer res.Body.Close()
+	v := &CanceledOrder{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode cancel margin order response")
+	}
+	return v, nil
+}
+
+// MarginAccount fetches the cross-margin account snapshot for the current
+// API key. Calling it on an isolated-scoped Service is valid but returns the
+// cross-margin account regardless of mode; use IsolatedMarginAccount for
+// isolated pairs.
+func (as *apiService) MarginAccount() (*MarginAccount, error) {
+	res, err := as.request("GET", "sapi/v1/margin/account", map[string]string{}, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch margin account")
+	}
+	defer res.Body.Close()
+	v := &MarginAccount{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode margin account response")
+	}
+	return v, nil
+}
+
+// IsolatedMarginAccount fetches one or more isolated-margin symbol pairs via
+// GET /sapi/v1/margin/isolated/account. With no symbols, Binance returns
+// every isolated pair the account holds.
+func (as *apiService) IsolatedMarginAccount(symbols ...string) (*IsolatedMarginAccount, error) {
+	params := map[string]string{}
+	if len(symbols) > 0 {
+		b, err := json.Marshal(symbols)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to encode symbols")
+		}
+		params["symbols"] = string(b)
+	}
+	res, err := as.request("GET", "sapi/v1/margin/isolated/account", params, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch isolated margin account")
+	}
+	defer res.Body.Close()
+	v := &IsolatedMarginAccount{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode isolated margin account response")
+	}
+	return v, nil
+}
+
+// MarginBorrow requests a new margin loan via POST /sapi/v1/margin/loan.
+func (as *apiService) MarginBorrow(mbr MarginLoanRequest) (*MarginTransaction, error) {
+	return as.marginLoanTransaction("sapi/v1/margin/loan", mbr)
+}
+
+// MarginRepay repays an outstanding margin loan via POST /sapi/v1/margin/repay.
+func (as *apiService) MarginRepay(mrr MarginLoanRequest) (*MarginTransaction, error) {
+	return as.marginLoanTransaction("sapi/v1/margin/repay", mrr)
+}
+
+func (as *apiService) marginLoanTransaction(endpoint string, lr MarginLoanRequest) (*MarginTransaction, error) {
+	params := map[string]string{
+		"asset":  lr.Asset,
+		"amount": strconv.FormatFloat(lr.Amount, 'f', -1, 64),
+	}
+	if as.Margin == marginIsolated {
+		params["isIsolated"] = "TRUE"
+		params["symbol"] = lr.Symbol
+	}
+	res, err := as.request("POST", endpoint, params, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to submit margin loan request")
+	}
+	defer res.Body.Close()
+	v := &MarginTransaction{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode margin loan response")
+	}
+	return v, nil
+}
+
+// MarginTransfer moves funds between the spot wallet and the cross-margin or
+// isolated-margin wallet depending on the Service's mode.
+func (as *apiService) MarginTransfer(mtr MarginTransferRequest) (*MarginTransaction, error) {
+	endpoint := "sapi/v1/margin/transfer"
+	params := map[string]string{
+		"asset":  mtr.Asset,
+		"amount": strconv.FormatFloat(mtr.Amount, 'f', -1, 64),
+	}
+	if as.Margin == marginIsolated {
+		endpoint = "sapi/v1/margin/isolated/transfer"
+		params["symbol"] = mtr.Symbol
+		if mtr.TransferType == 1 {
+			params["transFrom"] = "SPOT"
+			params["transTo"] = "ISOLATED_MARGIN"
+		} else {
+			params["transFrom"] = "ISOLATED_MARGIN"
+			params["transTo"] = "SPOT"
+		}
+	} else {
+		params["type"] = fmt.Sprintf("%d", mtr.TransferType)
+	}
+	res, err := as.request("POST", endpoint, params, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to submit margin transfer")
+	}
+	defer res.Body.Close()
+	v := &MarginTransaction{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode margin transfer response")
+	}
+	return v, nil
+}
@@ -0,0 +1,74 @@
+package binance
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// newOrderParams builds the signed query parameters shared by NewOrder and
+// NewOrderTest, validating and rounding Price/Quantity against the symbol
+// filters ExchangeInfo last cached (a no-op if ExchangeInfo was never
+// called).
+func (as *apiService) newOrderParams(or NewOrderRequest) (map[string]string, error) {
+	price, quantity, err := as.validateAndRoundOrder(or.Symbol, or.Price, or.Quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{
+		"symbol":   or.Symbol,
+		"side":     string(or.Side),
+		"type":     string(or.Type),
+		"quantity": strconv.FormatFloat(quantity, 'f', -1, 64),
+	}
+	if or.TimeInForce != "" {
+		params["timeInForce"] = string(or.TimeInForce)
+	}
+	if price != 0 {
+		params["price"] = strconv.FormatFloat(price, 'f', -1, 64)
+	}
+	if or.NewClientOrderID != "" {
+		params["newClientOrderId"] = or.NewClientOrderID
+	}
+	return params, nil
+}
+
+// NewOrder places a new spot order via POST /api/v3/order. Price and
+// Quantity are validated and rounded against the symbol's cached
+// PRICE_FILTER/LOT_SIZE/MIN_NOTIONAL filters before signing; see
+// ExchangeInfo. A *FilterError is returned, without making a request, if a
+// value falls outside those bounds.
+func (as *apiService) NewOrder(or NewOrderRequest) (*ProcessedOrder, error) {
+	params, err := as.newOrderParams(or)
+	if err != nil {
+		return nil, err
+	}
+	res, err := as.request("POST", "api/v3/order", params, true, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to place order")
+	}
+	defer res.Body.Close()
+	v := &ProcessedOrder{}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "unable to decode order response")
+	}
+	return v, nil
+}
+
+// NewOrderTest validates a spot order via POST /api/v3/order/test without
+// sending it to the matching engine. It applies the same filter validation
+// and rounding as NewOrder.
+func (as *apiService) NewOrderTest(or NewOrderRequest) error {
+	params, err := as.newOrderParams(or)
+	if err != nil {
+		return err
+	}
+	res, err := as.request("POST", "api/v3/order/test", params, true, true)
+	if err != nil {
+		return errors.Wrap(err, "unable to test order")
+	}
+	defer res.Body.Close()
+	return nil
+}
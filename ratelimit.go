@@ -0,0 +1,188 @@
+package binance
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// RateLimitError is returned when Binance responds 429 (Too Many Requests).
+// RetryAfter is how long the caller should wait before retrying, taken from
+// the Retry-After header when Binance sends one.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("binance: rate limited, retry after %s", e.RetryAfter)
+}
+
+// IPBanError is returned when Binance responds 418, meaning the calling IP
+// has been banned for continuing to send requests after being rate limited.
+type IPBanError struct {
+	RetryAfter time.Duration
+}
+
+func (e *IPBanError) Error() string {
+	return fmt.Sprintf("binance: IP banned, retry after %s", e.RetryAfter)
+}
+
+// weightSoftLimit and weightHardLimit bracket the point, out of Binance's
+// default 1200 request-weight budget per minute, where this client starts
+// spacing requests out on its own rather than waiting to be rejected with a
+// 429. Usage ramps linearly from no delay at weightSoftLimit to a full
+// second at weightHardLimit.
+const (
+	weightSoftLimit = 800
+	weightHardLimit = 1100
+
+	// orderCountSoftLimit is a conservative per-window order-count high
+	// water mark; Binance's actual limits vary by window (10s/1m/1d) and
+	// account tier, so this only needs to catch clearly excessive bursts.
+	orderCountSoftLimit = 40
+)
+
+// rateLimiter tracks the request-weight and order-count usage Binance
+// reports on every response. It pre-emptively spaces out requests as usage
+// approaches the exchange's limit, and backs off exponentially once Binance
+// actually rejects a request with 429 or 418.
+type rateLimiter struct {
+	logger log.Logger
+
+	mu             sync.Mutex
+	usedWeight1m   int
+	orderCount     map[string]int
+	blockedUntil   time.Time
+	currentBackoff time.Duration
+}
+
+func newRateLimiter(logger log.Logger) *rateLimiter {
+	return &rateLimiter{logger: logger, orderCount: map[string]int{}}
+}
+
+// wait blocks until any backoff period from a previous 429/418 has elapsed.
+// When no backoff is pending, it instead applies a smaller pre-emptive delay
+// if usage reported by the last response is already close to Binance's limit.
+func (rl *rateLimiter) wait() {
+	rl.mu.Lock()
+	until := rl.blockedUntil
+	weight := rl.usedWeight1m
+	orders := rl.maxOrderCountLocked()
+	rl.mu.Unlock()
+
+	if d := time.Until(until); d > 0 {
+		time.Sleep(d)
+		return
+	}
+	if d := preemptiveDelay(weight, orders); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+func (rl *rateLimiter) maxOrderCountLocked() int {
+	max := 0
+	for _, n := range rl.orderCount {
+		if n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// preemptiveDelay ramps from no delay at the soft limit to one second at the
+// hard limit, so throughput degrades gracefully instead of bursting into a
+// 429.
+func preemptiveDelay(usedWeight1m, maxOrderCount int) time.Duration {
+	if maxOrderCount >= orderCountSoftLimit {
+		return time.Second
+	}
+	switch {
+	case usedWeight1m >= weightHardLimit:
+		return time.Second
+	case usedWeight1m >= weightSoftLimit:
+		frac := float64(usedWeight1m-weightSoftLimit) / float64(weightHardLimit-weightSoftLimit)
+		return time.Duration(frac * float64(time.Second))
+	default:
+		return 0
+	}
+}
+
+// observe records the usage headers on res and returns a typed error if the
+// response indicates the caller has been rate limited or IP banned. The
+// backoff window it records is picked up by the next call to wait. Any
+// response other than 429/418 resets the exponential backoff back to its
+// starting point.
+func (rl *rateLimiter) observe(res *http.Response) error {
+	retryAfter := parseRetryAfter(res.Header.Get("Retry-After"))
+
+	if w := res.Header.Get("X-MBX-USED-WEIGHT-1M"); w != "" {
+		if n, err := strconv.Atoi(w); err == nil {
+			rl.mu.Lock()
+			rl.usedWeight1m = n
+			rl.mu.Unlock()
+			level.Debug(rl.logger).Log("usedWeight1m", n)
+		}
+	}
+	for key, vals := range res.Header {
+		if !strings.HasPrefix(key, "X-Mbx-Order-Count-") {
+			continue
+		}
+		if len(vals) == 0 {
+			continue
+		}
+		if n, err := strconv.Atoi(vals[0]); err == nil {
+			rl.mu.Lock()
+			rl.orderCount[key] = n
+			rl.mu.Unlock()
+			level.Debug(rl.logger).Log("orderCount", key, "value", n)
+		}
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests:
+		rl.backoff(retryAfter)
+		return &RateLimitError{RetryAfter: retryAfter}
+	case http.StatusTeapot: // Binance repurposes 418 as "IP auto-banned"
+		rl.backoff(retryAfter)
+		return &IPBanError{RetryAfter: retryAfter}
+	default:
+		rl.mu.Lock()
+		rl.currentBackoff = 0
+		rl.mu.Unlock()
+	}
+	return nil
+}
+
+// backoff grows the blocked window exponentially on each consecutive
+// 429/418 (capped at one minute via nextBackoff), using d - the Retry-After
+// header, when present - as a floor rather than a fixed window.
+func (rl *rateLimiter) backoff(d time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	next := time.Second
+	if rl.currentBackoff > 0 {
+		next = nextBackoff(rl.currentBackoff)
+	}
+	if d > next {
+		next = d
+	}
+	rl.currentBackoff = next
+	rl.blockedUntil = time.Now().Add(next)
+}
+
+func parseRetryAfter(h string) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	return 0
+}
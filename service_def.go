@@ -2,11 +2,9 @@ package binance
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
-	"net"
 	"net/http"
-	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -14,6 +12,12 @@ import (
 	"github.com/pkg/errors"
 )
 
+// defaultRecvWindowMillis bounds how long, in milliseconds, a SIGNED request
+// remains valid on Binance's side after its timestamp. Binance itself
+// defaults to 5000 if recvWindow is omitted; we send it explicitly so the
+// behavior doesn't depend on that default changing.
+const defaultRecvWindowMillis = 5000
+
 // Service represents service layer for Binance API.
 //
 // The main purpose for this layer is to be replaced with dummy implementation
@@ -27,7 +31,18 @@ type Service interface {
 	Ticker24(tr TickerRequest) (*Ticker24, error)
 	TickerAllPrices() ([]*PriceTicker, error)
 	TickerAllBooks() ([]*BookTicker, error)
-
+	// ExchangeInfo fetches per-symbol trading rules and precision filters
+	// (PRICE_FILTER, LOT_SIZE, MIN_NOTIONAL). NewOrder and NewOrderTest use
+	// the result, once fetched at least once, to round price/quantity to the
+	// symbol's tick/step size and reject out-of-range values up front with a
+	// *FilterError instead of a round trip to Binance.
+	ExchangeInfo() (*ExchangeInfo, error)
+	HistoricalTrades(htr HistoricalTradesRequest) ([]*Trade, error)
+
+	// NewOrder and NewOrderTest validate Price/Quantity against the symbol
+	// filters ExchangeInfo last cached, rounding to the filter's tick/step
+	// size and returning a *FilterError when a value falls outside the
+	// filter's min/max bounds.
 	NewOrder(or NewOrderRequest) (*ProcessedOrder, error)
 	NewOrderTest(or NewOrderRequest) error
 	QueryOrder(qor QueryOrderRequest) (*ExecutedOrder, error)
@@ -41,6 +56,25 @@ type Service interface {
 	DepositHistory(hr HistoryRequest) ([]*Deposit, error)
 	WithdrawHistory(hr HistoryRequest) ([]*Withdrawal, error)
 
+	// UseMargin returns a Service whose Margin* calls use cross-margin
+	// (isolated=false) or isolated-margin (isolated=true) trading endpoints.
+	// NewOrder, CancelOrder and the other spot calls on it still hit the spot
+	// endpoints; use MarginNewOrder and MarginCancelOrder for margin orders.
+	// The returned Service shares the same signer, logger and context, so it is
+	// cheap to request on the fly for a single call.
+	UseMargin(isolated bool) Service
+	MarginNewOrder(or NewOrderRequest) (*ProcessedOrder, error)
+	MarginCancelOrder(cor CancelOrderRequest) (*CanceledOrder, error)
+	MarginAccount() (*MarginAccount, error)
+	MarginBorrow(mbr MarginLoanRequest) (*MarginTransaction, error)
+	MarginRepay(mrr MarginLoanRequest) (*MarginTransaction, error)
+	MarginTransfer(mtr MarginTransferRequest) (*MarginTransaction, error)
+	IsolatedMarginAccount(symbols ...string) (*IsolatedMarginAccount, error)
+
+	// StartUserDataStream, KeepAliveUserDataStream and CloseUserDataStream
+	// operate on the spot user data stream. UseMargin does not affect them;
+	// there is no cross-margin or isolated-margin routing for user data
+	// streams yet.
 	StartUserDataStream() (*Stream, error)
 	KeepAliveUserDataStream(s *Stream) error
 	CloseUserDataStream(s *Stream) error
@@ -52,65 +86,71 @@ type Service interface {
 }
 
 type apiService struct {
-	URL    string
-	APIKey string
-	Signer Signer
-	Logger log.Logger
-	Ctx    context.Context
+	URL        string
+	APIKey     string
+	Signer     Signer
+	Logger     log.Logger
+	Ctx        context.Context
+	HTTPClient *http.Client
+	Limiter    *rateLimiter
+
+	// RecvWindowMillis is sent as recvWindow on every SIGNED request,
+	// defaulting to defaultRecvWindowMillis.
+	RecvWindowMillis int64
+
+	// Margin is the trading mode this service routes requests through.
+	// It defaults to marginNone (spot) and is set via UseMargin.
+	Margin marginMode
+	// IsolatedSymbol holds a symbol pair for isolated-margin use. UseMargin
+	// does not set it, and the Margin* calls take their symbol from each
+	// request rather than from this field.
+	IsolatedSymbol string
+
+	filters *symbolFilterCache
 }
 
 // NewAPIService creates instance of Service.
 //
 // If logger or ctx are not provided, NopLogger and Background context are used as default.
 // You can use context for one-time request cancel (e.g. when shutting down the app).
-func NewAPIService(url, apiKey string, signer Signer, logger log.Logger, ctx context.Context) Service {
+//
+// By default the client is built from DefaultHTTPConfig, which uses only the
+// environment's proxy settings and verifies TLS certificates. Pass WithHTTPConfig or WithHTTPClient to
+// override, and WithRateLimiter to throttle on Binance's usage headers.
+func NewAPIService(url, apiKey string, signer Signer, logger log.Logger, ctx context.Context, opts ...Option) Service {
 	if logger == nil {
 		logger = log.NewNopLogger()
 	}
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	return &apiService{
-		URL:    url,
-		APIKey: apiKey,
-		Signer: signer,
-		Logger: logger,
-		Ctx:    ctx,
+	o := newOptions()
+	for _, opt := range opts {
+		opt(o)
 	}
-}
-
-var (
-	client *http.Client
-)
-
-func init() {
-	if client == nil {
-		client = initHttpClient()
+	as := &apiService{
+		URL:              url,
+		APIKey:           apiKey,
+		Signer:           signer,
+		Logger:           logger,
+		Ctx:              ctx,
+		HTTPClient:       o.httpClient(),
+		RecvWindowMillis: defaultRecvWindowMillis,
+		filters:          newSymbolFilterCache(),
 	}
-}
-
-func initHttpClient() *http.Client {
-	client := &http.Client{
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   5 * time.Second,
-				KeepAlive: 5 * time.Second,
-			}).DialContext,
-			MaxIdleConns:        30,               //最大空闲连接数
-			MaxIdleConnsPerHost: 60,               //最大与服务器的连接数  默认是2
-			IdleConnTimeout:     30 * time.Second, //空闲连接保持时间
-			Proxy: func(_ *http.Request) (*url.URL, error) {
-				return url.Parse("http://127.0.0.1:7890")
-			},
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // disable verify
-		},
+	if o.rateLimiter {
+		as.Limiter = newRateLimiter(logger)
 	}
-	return client
+	return as
 }
 
 func (as *apiService) request(method string, endpoint string, params map[string]string,
 	apiKey bool, sign bool) (*http.Response, error) {
 
+	if as.Limiter != nil {
+		as.Limiter.wait()
+	}
+
 	url := fmt.Sprintf("%s/%s", as.URL, endpoint)
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
@@ -126,15 +166,25 @@ func (as *apiService) request(method string, endpoint string, params map[string]
 		req.Header.Add("X-MBX-APIKEY", as.APIKey)
 	}
 	if sign {
+		q.Set("timestamp", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+		if as.RecvWindowMillis > 0 {
+			q.Set("recvWindow", strconv.FormatInt(as.RecvWindowMillis, 10))
+		}
 		level.Debug(as.Logger).Log("queryString", q.Encode())
 		q.Add("signature", as.Signer.Sign([]byte(q.Encode())))
 		level.Debug(as.Logger).Log("signature", as.Signer.Sign([]byte(q.Encode())))
 	}
 	req.URL.RawQuery = q.Encode()
 
-	resp, err := client.Do(req)
+	resp, err := as.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	if as.Limiter != nil {
+		if err := as.Limiter.observe(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
 	return resp, nil
 }
@@ -0,0 +1,377 @@
+package binance
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const combinedStreamBaseURL = "wss://stream.binance.com:9443/stream"
+
+// StreamManager multiplexes any number of depth, kline, trade and user-data
+// subscriptions over a single combined-stream websocket connection,
+// reconnecting with exponential backoff and automatically resubscribing
+// everything that was active when the connection dropped. It has no ticker
+// subscription.
+//
+// Unlike DepthWebsocket/KlineWebsocket/TradeWebsocket/UserDataWebsocket,
+// which each open their own connection and give up on the first error,
+// StreamManager is meant to be created once and kept running for the
+// lifetime of the caller.
+type StreamManager struct {
+	service Service
+	logger  log.Logger
+
+	mu            sync.Mutex
+	conn          *streamConn
+	subscriptions map[string]*subscription
+	nextID        int
+	stopped       bool
+	stopCh        chan struct{}
+	// redialCh asks run's own goroutine to tear down and redial the
+	// connection with the current subscription set. run is the sole owner
+	// of conn.dial/conn.close; addSubscription and Unsubscribe only ever
+	// signal it rather than dialing themselves, so two goroutines never
+	// race to redial the same streamConn.
+	redialCh chan struct{}
+}
+
+// subscription is one caller-visible channel backed by a single combined
+// stream name (e.g. "btcusdt@depth" or "ethusdt@kline_1m").
+type subscription struct {
+	stream  string
+	deliver func(json.RawMessage)
+	// book is non-nil only for depth subscriptions, which need continuity
+	// tracking against a REST snapshot.
+	book *depthBuffer
+}
+
+// NewStreamManager creates a StreamManager that dials combinedStreamBaseURL
+// on the first Subscribe* call. service is used to fetch
+// REST order book snapshots for depth subscriptions.
+func NewStreamManager(service Service, logger log.Logger) *StreamManager {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	return &StreamManager{
+		service:       service,
+		logger:        logger,
+		subscriptions: map[string]*subscription{},
+		stopCh:        make(chan struct{}),
+		redialCh:      make(chan struct{}, 1),
+	}
+}
+
+// streamConn wraps a single websocket connection to a combined stream URL,
+// letting callers repoint it at a different cluster without losing the
+// higher-level subscription bookkeeping in StreamManager.
+type streamConn struct {
+	mu  sync.Mutex
+	url string
+	ws  *websocket.Conn
+}
+
+func newStreamConn(url string) *streamConn {
+	return &streamConn{url: url}
+}
+
+// UpdateURL repoints future (re)connects at url. It does not affect an
+// already-open connection; the new url is used from the next dial.
+func (c *streamConn) UpdateURL(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.url = url
+}
+
+func (c *streamConn) dial(streams []string) error {
+	c.mu.Lock()
+	url := fmt.Sprintf("%s?streams=%s", c.url, strings.Join(streams, "/"))
+	c.mu.Unlock()
+
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.ws = ws
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *streamConn) readMessage() (combinedStreamEvent, error) {
+	var evt combinedStreamEvent
+	c.mu.Lock()
+	ws := c.ws
+	c.mu.Unlock()
+	if ws == nil {
+		return evt, errors.New("stream connection is not open")
+	}
+	err := ws.ReadJSON(&evt)
+	return evt, err
+}
+
+func (c *streamConn) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ws != nil {
+		c.ws.Close()
+		c.ws = nil
+	}
+}
+
+// combinedStreamEvent is the envelope Binance wraps every payload in when
+// connecting to /stream?streams=....
+type combinedStreamEvent struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// SubscribeDepth opens (or adds to) the combined connection a diff-depth
+// subscription for symbol, maintaining a locally consistent order book: it
+// snapshots the REST book via Service.OrderBook, buffers diff events until
+// the snapshot's lastUpdateId has been passed, drops events with
+// u <= lastUpdateId, and replays the snapshot if it detects a gap (the next
+// event's U is not <= lastUpdateId+1 <= u).
+//
+// The subscription is registered, and therefore buffering live events,
+// before the REST snapshot is fetched: Binance's documented procedure is
+// connect-then-snapshot, not snapshot-then-connect, so anything that arrives
+// in between is queued rather than lost.
+func (sm *StreamManager) SubscribeDepth(symbol string) (chan *DepthEvent, error) {
+	out := make(chan *DepthEvent, 64)
+	buf := newDepthBuffer(sm.service, symbol, out, sm.logger)
+	stream := strings.ToLower(symbol) + "@depth"
+	sm.addSubscription(stream, &subscription{
+		stream: stream,
+		book:   buf,
+		deliver: func(raw json.RawMessage) {
+			buf.handle(raw)
+		},
+	})
+	go func() {
+		if err := buf.resync(); err != nil {
+			level.Error(sm.logger).Log("msg", "depth snapshot failed", "symbol", symbol, "err", err)
+		}
+	}()
+	return out, nil
+}
+
+// SubscribeKline opens a kline/candlestick subscription for symbol/interval.
+func (sm *StreamManager) SubscribeKline(symbol, interval string) (chan *KlineEvent, error) {
+	out := make(chan *KlineEvent, 64)
+	stream := fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), interval)
+	sm.addSubscription(stream, &subscription{
+		stream: stream,
+		deliver: func(raw json.RawMessage) {
+			v := &KlineEvent{}
+			if err := json.Unmarshal(raw, v); err != nil {
+				level.Error(sm.logger).Log("stream", stream, "err", err)
+				return
+			}
+			out <- v
+		},
+	})
+	return out, nil
+}
+
+// SubscribeTrade opens an aggTrade subscription for symbol.
+func (sm *StreamManager) SubscribeTrade(symbol string) (chan *AggTradeEvent, error) {
+	out := make(chan *AggTradeEvent, 64)
+	stream := strings.ToLower(symbol) + "@aggTrade"
+	sm.addSubscription(stream, &subscription{
+		stream: stream,
+		deliver: func(raw json.RawMessage) {
+			v := &AggTradeEvent{}
+			if err := json.Unmarshal(raw, v); err != nil {
+				level.Error(sm.logger).Log("stream", stream, "err", err)
+				return
+			}
+			out <- v
+		},
+	})
+	return out, nil
+}
+
+// SubscribeUserData opens the user-data stream identified by listenKey
+// (obtained via Service.StartUserDataStream). The caller remains responsible
+// for keeping the listen key alive.
+func (sm *StreamManager) SubscribeUserData(listenKey string) (chan *AccountEvent, error) {
+	out := make(chan *AccountEvent, 64)
+	stream := listenKey
+	sm.addSubscription(stream, &subscription{
+		stream: stream,
+		deliver: func(raw json.RawMessage) {
+			v := &AccountEvent{}
+			if err := json.Unmarshal(raw, v); err != nil {
+				level.Error(sm.logger).Log("stream", stream, "err", err)
+				return
+			}
+			out <- v
+		},
+	})
+	return out, nil
+}
+
+// Unsubscribe stops delivering events for stream and drops it from the
+// resubscribe list used after a reconnect. It does not close the channel
+// previously handed back by a Subscribe* call.
+func (sm *StreamManager) Unsubscribe(stream string) {
+	sm.mu.Lock()
+	delete(sm.subscriptions, stream)
+	sm.mu.Unlock()
+	sm.signalRedial()
+}
+
+// UpdateURL repoints the underlying connection at a new cluster. The change
+// takes effect on the next reconnect, so existing subscriptions are not torn
+// down. It has no effect before the first Subscribe* call has started the
+// connection, and a connection started again after every subscription was
+// dropped dials combinedStreamBaseURL.
+func (sm *StreamManager) UpdateURL(url string) {
+	sm.mu.Lock()
+	conn := sm.conn
+	sm.mu.Unlock()
+	if conn != nil {
+		conn.UpdateURL(url)
+	}
+}
+
+// Close stops the manager's reconnect loop and closes the underlying
+// connection. Channels returned by Subscribe* are left open; it is the
+// caller's responsibility to stop reading from them.
+func (sm *StreamManager) Close() {
+	sm.mu.Lock()
+	if sm.stopped {
+		sm.mu.Unlock()
+		return
+	}
+	sm.stopped = true
+	conn := sm.conn
+	sm.mu.Unlock()
+
+	close(sm.stopCh)
+	if conn != nil {
+		conn.close()
+	}
+}
+
+func (sm *StreamManager) addSubscription(stream string, sub *subscription) {
+	sm.mu.Lock()
+	first := len(sm.subscriptions) == 0
+	sm.subscriptions[stream] = sub
+	streams := sm.streamNamesLocked()
+	sm.mu.Unlock()
+
+	if first {
+		go sm.run(streams)
+		return
+	}
+	sm.signalRedial()
+}
+
+func (sm *StreamManager) streamNamesLocked() []string {
+	streams := make([]string, 0, len(sm.subscriptions))
+	for s := range sm.subscriptions {
+		streams = append(streams, s)
+	}
+	return streams
+}
+
+// signalRedial asks run to tear down and redial the connection with the
+// current subscription set. Binance's combined-stream endpoint takes its
+// subscription list in the URL, so there is no incremental SUBSCRIBE frame
+// to send - a redial is the only way to add or drop a stream. The send is
+// non-blocking: a redial already queued covers any subscription change that
+// happens before run picks it up.
+func (sm *StreamManager) signalRedial() {
+	select {
+	case sm.redialCh <- struct{}{}:
+	default:
+	}
+}
+
+// run owns the connection for the lifetime of the manager, reconnecting
+// with exponential backoff (capped at one minute) whenever the read loop
+// errors out, and replaying every live subscription's stream name on each
+// reconnect.
+func (sm *StreamManager) run(streams []string) {
+	conn := newStreamConn(combinedStreamBaseURL)
+	sm.mu.Lock()
+	sm.conn = conn
+	sm.mu.Unlock()
+
+	backoff := time.Second
+	for {
+		select {
+		case <-sm.stopCh:
+			return
+		default:
+		}
+
+		sm.mu.Lock()
+		streams = sm.streamNamesLocked()
+		sm.mu.Unlock()
+		if len(streams) == 0 {
+			return
+		}
+
+		if err := conn.dial(streams); err != nil {
+			level.Error(sm.logger).Log("msg", "unable to dial combined stream", "err", err)
+			time.Sleep(backoff)
+			backoff = nextBackoff(backoff)
+			continue
+		}
+		backoff = time.Second
+
+		// A redial request just needs to unblock the read loop below; conn
+		// is only ever closed here or by Close, never from the goroutine
+		// that requested the redial, so there is a single owner of
+		// conn.dial/conn.close for this connection's lifetime.
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-sm.redialCh:
+				conn.close()
+			case <-sm.stopCh:
+				conn.close()
+			case <-done:
+			}
+		}()
+
+		for {
+			evt, err := conn.readMessage()
+			if err != nil {
+				level.Error(sm.logger).Log("msg", "stream read failed, reconnecting", "err", err)
+				break
+			}
+			sm.mu.Lock()
+			sub, ok := sm.subscriptions[evt.Stream]
+			sm.mu.Unlock()
+			if ok {
+				sub.deliver(evt.Data)
+			}
+		}
+		close(done)
+
+		select {
+		case <-sm.stopCh:
+			return
+		default:
+		}
+	}
+}
+
+func nextBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > time.Minute {
+		return time.Minute
+	}
+	return d
+}